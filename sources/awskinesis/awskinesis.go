@@ -1,12 +1,19 @@
 package awskinesis
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"math/rand"
 	"sort"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/kinesis"
@@ -15,6 +22,54 @@ import (
 	validator "gopkg.in/go-playground/validator.v9"
 )
 
+// subscriptionLifetime is the duration AWS guarantees an Enhanced Fan-Out
+// subscription stays open before it must be re-established.
+const subscriptionLifetime = 5 * time.Minute
+
+// defaultMaxRetries caps retry attempts for throttled/5xx AWS calls when
+// AWSKinesis.MaxRetries is unset.
+const defaultMaxRetries = 5
+
+// backoffBase and backoffCap bound the exponential backoff applied between
+// retries of throttled or 5xx Kinesis calls.
+const (
+	backoffBase = 200 * time.Millisecond
+	backoffCap  = 10 * time.Second
+)
+
+// caughtUpSleep is the adaptive pause between polls once GetRecords reports
+// the consumer has no backlog left (MillisBehindLatest == 0), to stay under
+// Kinesis's 5 reads/sec/shard cap instead of poll-storming an empty shard.
+const caughtUpSleep = 1 * time.Second
+
+// consumerActivationPollInterval and consumerActivationMaxAttempts bound how
+// long registerStreamConsumer waits for a newly (or already) registered
+// Enhanced Fan-Out consumer to reach ACTIVE before giving up on Load.
+const (
+	consumerActivationPollInterval = 1 * time.Second
+	consumerActivationMaxAttempts  = 60
+)
+
+// decoderCloudWatchLogs treats each Kinesis record as a gzip-compressed
+// CloudWatch Logs subscription filter payload.
+const decoderCloudWatchLogs = "cloudwatch-logs"
+
+// cloudWatchLogsSubscriptionRecord is the JSON payload CloudWatch Logs delivers
+// to a Kinesis stream through a subscription filter.
+// See https://docs.aws.amazon.com/AmazonCloudWatch/latest/logs/SubscriptionFilters.html
+type cloudWatchLogsSubscriptionRecord struct {
+	MessageType         string   `json:"messageType"`
+	Owner               string   `json:"owner"`
+	LogGroup            string   `json:"logGroup"`
+	LogStream           string   `json:"logStream"`
+	SubscriptionFilters []string `json:"subscriptionFilters"`
+	LogEvents           []struct {
+		ID        string `json:"id"`
+		Timestamp int64  `json:"timestamp"`
+		Message   string `json:"message"`
+	} `json:"logEvents"`
+}
+
 // AWSKinesis is a configuration used to configure AWS Kinesis stream as a source.
 type AWSKinesis struct {
 	StreamName         string `json:"streamName" validate:"required"`
@@ -23,8 +78,95 @@ type AWSKinesis struct {
 	AWSSecretAccessKey string `json:"awsSecretAccessKey,omitempty"`
 	AWSSessionToken    string `json:"awsSessionToken,omitempty"`
 
-	shards  []*kinesis.Shard
-	service kinesisiface.KinesisAPI `validate:"-"`
+	// UseEnhancedFanOut switches Fetch from shared-throughput GetRecords polling
+	// to a dedicated Enhanced Fan-Out push subscription per shard.
+	UseEnhancedFanOut bool   `json:"useEnhancedFanOut,omitempty"`
+	ConsumerName      string `json:"consumerName,omitempty"`
+
+	// Decoder selects how raw Kinesis record payloads are unpacked before being
+	// handed off as connection.Records.Data. Defaults to "raw" (no decoding).
+	Decoder string `json:"decoder,omitempty" validate:"omitempty,oneof=raw cloudwatch-logs"`
+
+	// InitialPosition controls where a shard is first read from when a connection
+	// has no lastSeq yet. Defaults to TRIM_HORIZON. Ignored once a shard has a
+	// lastSeq, which always resumes via AFTER_SEQUENCE_NUMBER.
+	InitialPosition  string    `json:"initialPosition,omitempty" validate:"omitempty,oneof=TRIM_HORIZON LATEST AT_TIMESTAMP"`
+	InitialTimestamp time.Time `json:"initialTimestamp,omitempty"`
+
+	// ReshardPollInterval controls how often WatchReshard polls Reshard to
+	// detect Kinesis shard splits/merges. Zero disables automatic
+	// reconciliation: WatchReshard returns an already-closed channel and the
+	// pool driver must call Reshard itself if it wants one-off reconciliation.
+	ReshardPollInterval time.Duration `json:"reshardPollInterval,omitempty"`
+
+	// MaxRetries bounds how many times a throttled or 5xx Kinesis call is
+	// retried with exponential backoff before Fetch gives up. Zero (the Go
+	// zero value, indistinguishable from unset) falls back to defaultMaxRetries.
+	MaxRetries int `json:"maxRetries,omitempty" validate:"omitempty,min=1"`
+
+	shardState  *shardState
+	service     kinesisiface.KinesisAPI `validate:"-"`
+	streamARN   string
+	consumerARN string
+
+	subMu         *sync.Mutex
+	subscriptions map[uint]*efoSubscription
+	closedShards  map[uint]bool
+}
+
+// shardState holds the mutable, shared view of a stream's shards so that it
+// survives across the value-receiver method calls AWSKinesis is invoked with.
+type shardState struct {
+	mu     sync.RWMutex
+	shards []*kinesis.Shard
+}
+
+// ShardChangeType describes whether a ShardChange adds or retires a shard.
+type ShardChangeType string
+
+const (
+	// ShardAdded indicates a shard (initial, split or merge child) became eligible for consumption.
+	ShardAdded ShardChangeType = "ADDED"
+	// ShardClosed indicates Kinesis closed a shard to new writes (its
+	// EndingSequenceNumber is set); records already in it may still be unread.
+	ShardClosed ShardChangeType = "CLOSED"
+)
+
+// ShardChange describes a single shard addition or retirement discovered by Reshard.
+type ShardChange struct {
+	Type                  ShardChangeType
+	ShardID               uint
+	ParentShardID         string
+	AdjacentParentShardID string
+}
+
+// DynamicSource is an optional connection.Source extension for sources whose
+// worker set can change at runtime, such as a Kinesis stream being resharded.
+// A pool driver that wants to spawn/retire workers as shards come and go
+// should type-assert its connection.Source against DynamicSource and, when it
+// implements it, consume WatchReshard's channel instead of treating
+// NumberOfWorkers as fixed for the lifetime of the source. No such driver
+// change ships with this package; AWSKinesis only provides the source-side
+// half of the contract.
+type DynamicSource interface {
+	connection.Source
+	Reshard(ctx context.Context) ([]ShardChange, error)
+	WatchReshard(ctx context.Context) <-chan ShardChange
+}
+
+var _ DynamicSource = AWSKinesis{}
+
+// efoSubscription tracks a live Enhanced Fan-Out SubscribeToShard stream for a single shard.
+type efoSubscription struct {
+	cancel  context.CancelFunc
+	events  chan *kinesis.SubscribeToShardEvent
+	lastSeq string
+
+	// err is the reason the event stream ended, if any. It is written by the
+	// forwarding goroutine strictly before it closes events, so reading it
+	// after observing events closed is race-free: the happens-before edge
+	// comes from the channel close itself, not from a separate signal.
+	err error
 }
 
 func init() {
@@ -45,6 +187,10 @@ func Load(data []byte) (connection.Source, error) {
 		return nil, fmt.Errorf("missing required fields for awskinesis source: %s", err.Error())
 	}
 
+	if src.InitialPosition == "AT_TIMESTAMP" && src.InitialTimestamp.IsZero() {
+		return nil, fmt.Errorf("awskinesis source: initialTimestamp is required when initialPosition is AT_TIMESTAMP")
+	}
+
 	conf := aws.NewConfig().WithRegion(src.Region)
 	if src.AWSAccessKeyID != "" && src.AWSSecretAccessKey != "" {
 		conf = conf.WithCredentials(
@@ -62,6 +208,9 @@ func Load(data []byte) (connection.Source, error) {
 	}
 
 	src.service = kinesis.New(awsSession)
+	src.subMu = &sync.Mutex{}
+	src.subscriptions = make(map[uint]*efoSubscription)
+	src.closedShards = make(map[uint]bool)
 
 	stream, err := src.service.DescribeStream(
 		&kinesis.DescribeStreamInput{
@@ -74,53 +223,600 @@ func Load(data []byte) (connection.Source, error) {
 	sort.Slice(stream.StreamDescription.Shards, func(i, j int) bool {
 		return *stream.StreamDescription.Shards[i].ShardId < *stream.StreamDescription.Shards[j].ShardId
 	})
-	src.shards = stream.StreamDescription.Shards
+	src.shardState = &shardState{shards: stream.StreamDescription.Shards}
+	src.streamARN = *stream.StreamDescription.StreamARN
+
+	if src.UseEnhancedFanOut {
+		consumerARN, err := src.registerStreamConsumer()
+		if err != nil {
+			return src, fmt.Errorf("unable to register awskinesis stream consumer: %s", err.Error())
+		}
+		src.consumerARN = consumerARN
+	}
 
 	return src, nil
 }
 
+// registerStreamConsumer idempotently registers (or reuses) an Enhanced Fan-Out
+// consumer for the stream and blocks until it reaches ACTIVE state, retrying
+// throttled/5xx AWS calls and giving up after consumerActivationMaxAttempts
+// rather than waiting forever on a stuck consumer.
+func (a AWSKinesis) registerStreamConsumer() (string, error) {
+	ctx := context.Background()
+
+	var registered *kinesis.RegisterStreamConsumerOutput
+	registerErr := a.withRetry(ctx, func() error {
+		var err error
+		registered, err = a.service.RegisterStreamConsumer(&kinesis.RegisterStreamConsumerInput{
+			StreamARN:    aws.String(a.streamARN),
+			ConsumerName: aws.String(a.ConsumerName),
+		})
+		return err
+	})
+
+	var consumerARN string
+	if registerErr != nil {
+		if awsErr, ok := registerErr.(interface{ Code() string }); ok && awsErr.Code() == kinesis.ErrCodeResourceInUseException {
+			var existing *kinesis.DescribeStreamConsumerOutput
+			descErr := a.withRetry(ctx, func() error {
+				var err error
+				existing, err = a.service.DescribeStreamConsumer(&kinesis.DescribeStreamConsumerInput{
+					StreamARN:    aws.String(a.streamARN),
+					ConsumerName: aws.String(a.ConsumerName),
+				})
+				return err
+			})
+			if descErr != nil {
+				return "", descErr
+			}
+			consumerARN = *existing.ConsumerDescription.ConsumerARN
+		} else {
+			return "", registerErr
+		}
+	} else {
+		consumerARN = *registered.Consumer.ConsumerARN
+	}
+
+	for attempt := 0; attempt < consumerActivationMaxAttempts; attempt++ {
+		var desc *kinesis.DescribeStreamConsumerOutput
+		err := a.withRetry(ctx, func() error {
+			var err error
+			desc, err = a.service.DescribeStreamConsumer(&kinesis.DescribeStreamConsumerInput{
+				ConsumerARN: aws.String(consumerARN),
+			})
+			return err
+		})
+		if err != nil {
+			return "", err
+		}
+		if *desc.ConsumerDescription.ConsumerStatus == kinesis.ConsumerStatusActive {
+			return consumerARN, nil
+		}
+		if !sleepFor(ctx, consumerActivationPollInterval) {
+			return "", ctx.Err()
+		}
+	}
+	return "", fmt.Errorf("awskinesis consumer %s did not reach ACTIVE after %d attempts", a.ConsumerName, consumerActivationMaxAttempts)
+}
+
 // Fetch retrieves the next document from the awskinesis source
 // Borrrowed some items from https://github.com/harlow/kinesis-consumer/blob/master/consumer.go#L251
 func (a AWSKinesis) Fetch(ctx context.Context, shardID uint, lastSeq string) (*connection.Records, error) {
+	if a.UseEnhancedFanOut {
+		return a.fetchEnhancedFanOut(ctx, shardID, lastSeq)
+	}
+
 	ret := &connection.Records{LastSequence: lastSeq}
+	maxRetries := a.maxRetries()
+
+	var iter *string
+	if err := a.withRetry(ctx, func() error {
+		var err error
+		iter, err = a.getShardIterator(ctx, shardID, lastSeq)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		records, err := a.service.GetRecordsWithContext(ctx, &kinesis.GetRecordsInput{
+			ShardIterator: iter,
+		})
+		if err != nil {
+			if attempt < maxRetries && isExpiredIteratorError(err) {
+				// The iterator expired: get a fresh one from lastSeq right away and
+				// retry GetRecords with it, no backoff needed for this case.
+				iter, err = a.getShardIterator(ctx, shardID, lastSeq)
+				if err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if attempt < maxRetries && isRetryableError(err) {
+				if !sleepBackoff(ctx, attempt) {
+					return nil, ctx.Err()
+				}
+				continue
+			}
+			return nil, err
+		}
+
+		for _, rec := range records.Records {
+			messages, err := a.decodeRecord(rec.Data)
+			if err != nil {
+				return nil, err
+			}
+			ret.Data = append(ret.Data, messages...)
+			ret.LastSequence = *rec.SequenceNumber
+		}
+
+		if len(records.Records) == 0 && records.MillisBehindLatest != nil && *records.MillisBehindLatest == 0 {
+			if !sleepFor(ctx, caughtUpSleep) {
+				return nil, ctx.Err()
+			}
+		}
+
+		return ret, nil
+	}
+}
+
+// getShardIterator obtains a shard iterator for shardID, honoring lastSeq for
+// resumption and a.InitialPosition for the first read of a shard.
+func (a AWSKinesis) getShardIterator(ctx context.Context, shardID uint, lastSeq string) (*string, error) {
 	params := &kinesis.GetShardIteratorInput{
-		ShardId:           a.shards[shardID].ShardId,
-		StreamName:        aws.String(a.StreamName),
-		ShardIteratorType: aws.String("TRIM_HORIZON"),
+		ShardId:    a.shard(shardID).ShardId,
+		StreamName: aws.String(a.StreamName),
 	}
 
 	if len(lastSeq) != 0 {
 		params.ShardIteratorType = aws.String("AFTER_SEQUENCE_NUMBER")
 		params.StartingSequenceNumber = aws.String(lastSeq)
+	} else {
+		params.ShardIteratorType = aws.String(a.initialPosition())
+		if a.InitialPosition == "AT_TIMESTAMP" {
+			params.Timestamp = aws.Time(a.InitialTimestamp)
+		}
 	}
 
-	// set up the shard iterator for our particular shardID
 	iter, err := a.service.GetShardIteratorWithContext(ctx, params)
 	if err != nil {
 		return nil, err
 	}
+	return iter.ShardIterator, nil
+}
+
+// maxRetries returns the configured retry budget, defaulting to defaultMaxRetries.
+func (a AWSKinesis) maxRetries() int {
+	if a.MaxRetries > 0 {
+		return a.MaxRetries
+	}
+	return defaultMaxRetries
+}
+
+// isRetryableError reports whether err is a throttling or 5xx AWS error worth
+// retrying with backoff (ExpiredIteratorException is handled separately).
+func isRetryableError(err error) bool {
+	awsErr, ok := err.(awserr.Error)
+	if !ok {
+		return false
+	}
+	switch awsErr.Code() {
+	case kinesis.ErrCodeProvisionedThroughputExceededException, kinesis.ErrCodeLimitExceededException:
+		return true
+	}
+	if reqErr, ok := err.(awserr.RequestFailure); ok && reqErr.StatusCode() >= 500 {
+		return true
+	}
+	return false
+}
+
+// isExpiredIteratorError reports whether err is ErrCodeExpiredIteratorException.
+func isExpiredIteratorError(err error) bool {
+	awsErr, ok := err.(awserr.Error)
+	return ok && awsErr.Code() == kinesis.ErrCodeExpiredIteratorException
+}
+
+// sleepBackoff waits out a full-jitter exponential backoff for the given retry
+// attempt (0-indexed), returning false if ctx is done first.
+func sleepBackoff(ctx context.Context, attempt int) bool {
+	return sleepFor(ctx, time.Duration(rand.Int63n(int64(backoffCeiling(attempt)))))
+}
+
+// backoffCeiling returns the upper bound of the full-jitter window for the
+// given retry attempt (0-indexed), clamped to backoffCap. It also guards
+// against the left-shift overflowing into a negative or zero duration for
+// large attempt counts.
+func backoffCeiling(attempt int) time.Duration {
+	ceiling := backoffBase * time.Duration(1<<uint(attempt))
+	if ceiling <= 0 || ceiling > backoffCap {
+		ceiling = backoffCap
+	}
+	return ceiling
+}
 
-	records, err := a.service.GetRecordsWithContext(ctx, &kinesis.GetRecordsInput{
-		ShardIterator: iter.ShardIterator,
+// sleepFor waits for d, returning false if ctx is done first.
+func sleepFor(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// withRetry runs fn, retrying with exponential backoff while fn's error is a
+// throttling/5xx AWS error and the retry budget isn't exhausted. Used to wrap
+// the AWS calls behind registerStreamConsumer and the EFO subscribe call,
+// same as Fetch's GetRecords/GetShardIterator path.
+func (a AWSKinesis) withRetry(ctx context.Context, fn func() error) error {
+	maxRetries := a.maxRetries()
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || !isRetryableError(err) || attempt >= maxRetries {
+			return err
+		}
+		if !sleepBackoff(ctx, attempt) {
+			return ctx.Err()
+		}
+	}
+}
+
+// shard returns the shard currently known at shardID.
+func (a AWSKinesis) shard(shardID uint) *kinesis.Shard {
+	a.shardState.mu.RLock()
+	defer a.shardState.mu.RUnlock()
+	return a.shardState.shards[shardID]
+}
+
+// shardCount returns the number of shards currently known.
+func (a AWSKinesis) shardCount() int {
+	a.shardState.mu.RLock()
+	defer a.shardState.mu.RUnlock()
+	return len(a.shardState.shards)
+}
+
+// Reshard reconciles a.shardState against a fresh ListShards call, appending
+// newly-eligible shards (splits/merges whose parents already reached SHARD_END)
+// and reporting any previously-open shard that has since reached SHARD_END.
+// It is one of the two methods AWSKinesis implements to satisfy DynamicSource;
+// WatchReshard is the polling wrapper pool drivers should actually consume.
+//
+// A parent being closed here only means Kinesis has stopped accepting writes
+// to it (its SequenceNumberRange.EndingSequenceNumber is set) — it does not
+// mean this consumer has drained the parent's backlog yet. To approximate the
+// KCL invariant without a signal from the pool driver's own Fetch loop, a
+// child only becomes eligible once its parent was already closed as of the
+// *previous* Reshard call (tracked via the shard's state already stored in
+// a.shardState, not the freshly-listed one): a child and its parent's closure
+// are never reported in the same call, so at least one poll interval separates
+// the parent's ShardClosed from the child's ShardAdded. Fetch does not report
+// when a shard's records are exhausted (it ignores GetRecords'
+// NextShardIterator), so with a large backlog the child can still appear
+// before the parent is fully drained.
+func (a AWSKinesis) Reshard(ctx context.Context) ([]ShardChange, error) {
+	listed, err := a.listShards(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list awskinesis shards: %s", err.Error())
+	}
+
+	a.shardState.mu.Lock()
+	defer a.shardState.mu.Unlock()
+
+	known := make(map[string]int, len(a.shardState.shards))
+	locallyClosed := make(map[string]bool, len(a.shardState.shards))
+	for i, s := range a.shardState.shards {
+		known[*s.ShardId] = i
+		if s.SequenceNumberRange != nil && s.SequenceNumberRange.EndingSequenceNumber != nil {
+			locallyClosed[*s.ShardId] = true
+		}
+	}
+
+	// eligibleParent reports whether shardID can gate a child's ShardAdded this
+	// call: either it was already closed as of our last reconciliation, or we
+	// never tracked it at all (e.g. it aged out of the retention window before
+	// we ever saw it, so there's nothing local left to drain).
+	eligibleParent := func(shardID *string) bool {
+		if shardID == nil {
+			return true
+		}
+		if locallyClosed[*shardID] {
+			return true
+		}
+		_, trackedLocally := known[*shardID]
+		return !trackedLocally
+	}
+
+	var changes []ShardChange
+
+	for _, s := range listed {
+		i, ok := known[*s.ShardId]
+		if !ok {
+			continue
+		}
+		nowClosed := s.SequenceNumberRange != nil && s.SequenceNumberRange.EndingSequenceNumber != nil
+		if nowClosed && !locallyClosed[*s.ShardId] {
+			a.shardState.shards[i] = s
+			changes = append(changes, ShardChange{Type: ShardClosed, ShardID: uint(i)})
+		}
+	}
+
+	for _, s := range listed {
+		if _, ok := known[*s.ShardId]; ok {
+			continue
+		}
+		if !eligibleParent(s.ParentShardId) || !eligibleParent(s.AdjacentParentShardId) {
+			continue
+		}
+
+		change := ShardChange{Type: ShardAdded, ShardID: uint(len(a.shardState.shards))}
+		if s.ParentShardId != nil {
+			change.ParentShardID = *s.ParentShardId
+		}
+		if s.AdjacentParentShardId != nil {
+			change.AdjacentParentShardID = *s.AdjacentParentShardId
+		}
+		a.shardState.shards = append(a.shardState.shards, s)
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+// WatchReshard polls Reshard every ReshardPollInterval and publishes the
+// resulting ShardChange events on the returned channel, which is closed when
+// ctx is done. If ReshardPollInterval is unset, reconciliation is disabled:
+// WatchReshard returns an already-closed channel and does no polling at all.
+// Reshard errors (e.g. a throttled ListShards past its own retry budget) are
+// swallowed and retried on the next tick rather than tearing down the watch.
+func (a AWSKinesis) WatchReshard(ctx context.Context) <-chan ShardChange {
+	changes := make(chan ShardChange)
+	if a.ReshardPollInterval <= 0 {
+		close(changes)
+		return changes
+	}
+
+	go func() {
+		defer close(changes)
+		ticker := time.NewTicker(a.ReshardPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				found, err := a.Reshard(ctx)
+				if err != nil {
+					continue
+				}
+				for _, change := range found {
+					select {
+					case changes <- change:
+					case <-ctx.Done():
+						return
+					}
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return changes
+}
+
+// listShards pages through ListShards for the configured stream.
+func (a AWSKinesis) listShards(ctx context.Context) ([]*kinesis.Shard, error) {
+	var shards []*kinesis.Shard
+	input := &kinesis.ListShardsInput{StreamName: aws.String(a.StreamName)}
+	for {
+		out, err := a.service.ListShardsWithContext(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		shards = append(shards, out.Shards...)
+		if out.NextToken == nil {
+			return shards, nil
+		}
+		input = &kinesis.ListShardsInput{NextToken: out.NextToken}
+	}
+}
+
+// initialPosition returns the shard iterator type to use for the first read of
+// a shard, defaulting to TRIM_HORIZON when InitialPosition is unset.
+func (a AWSKinesis) initialPosition() string {
+	if a.InitialPosition == "" {
+		return "TRIM_HORIZON"
+	}
+	return a.InitialPosition
+}
+
+// decodeRecord unpacks a single Kinesis record's payload according to a.Decoder,
+// returning the entries that should be emitted as connection.Records.Data.
+func (a AWSKinesis) decodeRecord(data []byte) ([][]byte, error) {
+	if a.Decoder != decoderCloudWatchLogs {
+		return [][]byte{data}, nil
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unable to gunzip cloudwatch-logs record: %s", err.Error())
+	}
+	defer reader.Close()
+
+	uncompressed, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("unable to gunzip cloudwatch-logs record: %s", err.Error())
+	}
+
+	var sub cloudWatchLogsSubscriptionRecord
+	if err := json.Unmarshal(uncompressed, &sub); err != nil {
+		return nil, fmt.Errorf("unable to decode cloudwatch-logs record: %s", err.Error())
+	}
+
+	if sub.MessageType == "CONTROL_MESSAGE" {
+		return nil, nil
+	}
+
+	messages := make([][]byte, 0, len(sub.LogEvents))
+	for _, event := range sub.LogEvents {
+		messages = append(messages, []byte(event.Message))
+	}
+	return messages, nil
+}
+
+// fetchEnhancedFanOut returns the next batch of records pushed over the shard's
+// Enhanced Fan-Out subscription, transparently (re-)subscribing as needed.
+func (a AWSKinesis) fetchEnhancedFanOut(ctx context.Context, shardID uint, lastSeq string) (*connection.Records, error) {
+	sub, err := a.subscription(ctx, shardID, lastSeq)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case event, ok := <-sub.events:
+		if !ok {
+			// The stream ended, either because it genuinely failed (sub.err set) or
+			// because it was proactively rotated at subscriptionLifetime (sub.err
+			// nil). Check sub.err directly instead of racing a second channel: the
+			// forwarding goroutine always sets it before closing events, so there's
+			// no ambiguity about which case "wins".
+			a.closeSubscription(shardID)
+			if sub.err != nil {
+				return nil, sub.err
+			}
+			return a.fetchEnhancedFanOut(ctx, shardID, sub.lastSeq)
+		}
+		ret := &connection.Records{LastSequence: sub.lastSeq}
+		for _, rec := range event.Records {
+			messages, err := a.decodeRecord(rec.Data)
+			if err != nil {
+				return nil, err
+			}
+			ret.Data = append(ret.Data, messages...)
+		}
+		if event.ContinuationSequenceNumber != nil {
+			ret.LastSequence = *event.ContinuationSequenceNumber
+			sub.lastSeq = *event.ContinuationSequenceNumber
+		}
+		return ret, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// subscription returns the live subscription for shardID, establishing one
+// (honoring lastSeq for resumption) if none exists yet.
+func (a AWSKinesis) subscription(ctx context.Context, shardID uint, lastSeq string) (*efoSubscription, error) {
+	a.subMu.Lock()
+	defer a.subMu.Unlock()
+
+	if sub, ok := a.subscriptions[shardID]; ok {
+		return sub, nil
+	}
+
+	var startingPosition *kinesis.StartingPosition
+	if lastSeq != "" {
+		startingPosition = &kinesis.StartingPosition{
+			Type:           aws.String(kinesis.ShardIteratorTypeAfterSequenceNumber),
+			SequenceNumber: aws.String(lastSeq),
+		}
+	} else {
+		startingPosition = &kinesis.StartingPosition{Type: aws.String(a.initialPosition())}
+		if a.InitialPosition == "AT_TIMESTAMP" {
+			startingPosition.Timestamp = aws.Time(a.InitialTimestamp)
+		}
+	}
+
+	subCtx, cancel := context.WithCancel(ctx)
+	var out *kinesis.SubscribeToShardOutput
+	err := a.withRetry(subCtx, func() error {
+		var err error
+		out, err = a.service.SubscribeToShardWithContext(subCtx, &kinesis.SubscribeToShardInput{
+			ConsumerARN:      aws.String(a.consumerARN),
+			ShardId:          a.shard(shardID).ShardId,
+			StartingPosition: startingPosition,
+		})
+		return err
 	})
 	if err != nil {
+		cancel()
 		return nil, err
 	}
 
-	for _, rec := range records.Records {
-		ret.Data = append(ret.Data, rec.Data)
-		ret.LastSequence = *rec.SequenceNumber
+	sub := &efoSubscription{
+		cancel:  cancel,
+		events:  make(chan *kinesis.SubscribeToShardEvent),
+		lastSeq: lastSeq,
 	}
+	a.subscriptions[shardID] = sub
+
+	go func() {
+		defer close(sub.events)
+		eventStream := out.GetEventStream()
+		for {
+			event, ok := <-eventStream.Events()
+			if !ok {
+				sub.err = eventStream.Err()
+				return
+			}
+			if e, ok := event.(*kinesis.SubscribeToShardEvent); ok {
+				select {
+				case sub.events <- e:
+				case <-subCtx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	// An Enhanced Fan-Out subscription auto-expires after subscriptionLifetime;
+	// drop it proactively so the next Fetch transparently re-subscribes.
+	go func() {
+		select {
+		case <-time.After(subscriptionLifetime):
+			a.closeSubscription(shardID)
+		case <-subCtx.Done():
+		}
+	}()
+
+	return sub, nil
+}
+
+// closeSubscription tears down and forgets the live subscription for shardID, if any.
+func (a AWSKinesis) closeSubscription(shardID uint) {
+	a.subMu.Lock()
+	defer a.subMu.Unlock()
 
-	return ret, nil
+	if sub, ok := a.subscriptions[shardID]; ok {
+		sub.cancel()
+		delete(a.subscriptions, shardID)
+	}
 }
 
 // NumberOfWorkers returns number of shards to handle by the pool
 func (a AWSKinesis) NumberOfWorkers() uint {
-	return uint(len(a.shards))
+	return uint(a.shardCount())
 }
 
-// Close no-op.
-func (a AWSKinesis) Close(_ uint) error {
-	return nil
+// Close tears down any live Enhanced Fan-Out subscription for shardID and,
+// when enabled, deregisters the stream consumer once every known shard has
+// been closed (tracked against a.shardCount(), not live-subscription count,
+// since subscriptions are only created lazily on a shard's first Fetch).
+func (a AWSKinesis) Close(shardID uint) error {
+	a.closeSubscription(shardID)
+
+	if !a.UseEnhancedFanOut || a.consumerARN == "" {
+		return nil
+	}
+
+	a.subMu.Lock()
+	a.closedShards[shardID] = true
+	allClosed := len(a.closedShards) >= a.shardCount()
+	a.subMu.Unlock()
+	if !allClosed {
+		return nil
+	}
+
+	_, err := a.service.DeregisterStreamConsumer(&kinesis.DeregisterStreamConsumerInput{
+		ConsumerARN: aws.String(a.consumerARN),
+	})
+	return err
 }
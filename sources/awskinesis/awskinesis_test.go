@@ -0,0 +1,548 @@
+package awskinesis
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/aws/aws-sdk-go/service/kinesis/kinesisiface"
+)
+
+// fakeKinesis embeds kinesisiface.KinesisAPI so it satisfies the interface
+// without implementing every method, overriding only what the code under
+// test calls. Each overridden call delegates to an optional func field so
+// individual tests can drive just the behavior they need.
+type fakeKinesis struct {
+	kinesisiface.KinesisAPI
+	shards []*kinesis.Shard
+
+	registerStreamConsumerFn   func(*kinesis.RegisterStreamConsumerInput) (*kinesis.RegisterStreamConsumerOutput, error)
+	describeStreamConsumerFn   func(*kinesis.DescribeStreamConsumerInput) (*kinesis.DescribeStreamConsumerOutput, error)
+	deregisterStreamConsumerFn func(*kinesis.DeregisterStreamConsumerInput) (*kinesis.DeregisterStreamConsumerOutput, error)
+	subscribeToShardFn         func(*kinesis.SubscribeToShardInput) (*kinesis.SubscribeToShardOutput, error)
+	getShardIteratorFn         func(*kinesis.GetShardIteratorInput) (*kinesis.GetShardIteratorOutput, error)
+}
+
+func (f *fakeKinesis) ListShardsWithContext(ctx aws.Context, in *kinesis.ListShardsInput, opts ...request.Option) (*kinesis.ListShardsOutput, error) {
+	return &kinesis.ListShardsOutput{Shards: f.shards}, nil
+}
+
+func (f *fakeKinesis) RegisterStreamConsumer(in *kinesis.RegisterStreamConsumerInput) (*kinesis.RegisterStreamConsumerOutput, error) {
+	return f.registerStreamConsumerFn(in)
+}
+
+func (f *fakeKinesis) DescribeStreamConsumer(in *kinesis.DescribeStreamConsumerInput) (*kinesis.DescribeStreamConsumerOutput, error) {
+	return f.describeStreamConsumerFn(in)
+}
+
+func (f *fakeKinesis) DeregisterStreamConsumer(in *kinesis.DeregisterStreamConsumerInput) (*kinesis.DeregisterStreamConsumerOutput, error) {
+	return f.deregisterStreamConsumerFn(in)
+}
+
+func (f *fakeKinesis) SubscribeToShardWithContext(ctx aws.Context, in *kinesis.SubscribeToShardInput, opts ...request.Option) (*kinesis.SubscribeToShardOutput, error) {
+	return f.subscribeToShardFn(in)
+}
+
+func (f *fakeKinesis) GetShardIteratorWithContext(ctx aws.Context, in *kinesis.GetShardIteratorInput, opts ...request.Option) (*kinesis.GetShardIteratorOutput, error) {
+	return f.getShardIteratorFn(in)
+}
+
+// fakeEventStreamReader drives a kinesis.SubscribeToShardEventStream in
+// tests, standing in for the real network reader the SDK installs on
+// SubscribeToShardOutput.EventStream.Reader.
+type fakeEventStreamReader struct {
+	events chan kinesis.SubscribeToShardEventStreamEvent
+	err    error
+}
+
+func (f *fakeEventStreamReader) Events() <-chan kinesis.SubscribeToShardEventStreamEvent {
+	return f.events
+}
+
+func (f *fakeEventStreamReader) Close() error { return nil }
+
+func (f *fakeEventStreamReader) Err() error { return f.err }
+
+func openShard(id string) *kinesis.Shard {
+	return &kinesis.Shard{ShardId: aws.String(id)}
+}
+
+func closedShard(id string) *kinesis.Shard {
+	return &kinesis.Shard{
+		ShardId:             aws.String(id),
+		SequenceNumberRange: &kinesis.SequenceNumberRange{EndingSequenceNumber: aws.String("999")},
+	}
+}
+
+func childShard(id, parentID string) *kinesis.Shard {
+	return &kinesis.Shard{ShardId: aws.String(id), ParentShardId: aws.String(parentID)}
+}
+
+func gzipJSON(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal: %s", err)
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("gzip write: %s", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("gzip close: %s", err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeRecord_RawPassThrough(t *testing.T) {
+	a := AWSKinesis{}
+	data := []byte("hello world")
+
+	messages, err := a.decodeRecord(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(messages) != 1 || string(messages[0]) != "hello world" {
+		t.Fatalf("expected raw payload passed through unchanged, got %v", messages)
+	}
+}
+
+func TestDecodeRecord_CloudWatchLogs(t *testing.T) {
+	a := AWSKinesis{Decoder: decoderCloudWatchLogs}
+	sub := cloudWatchLogsSubscriptionRecord{
+		MessageType: "DATA_MESSAGE",
+		LogGroup:    "/my/log/group",
+		LogEvents: []struct {
+			ID        string `json:"id"`
+			Timestamp int64  `json:"timestamp"`
+			Message   string `json:"message"`
+		}{
+			{ID: "1", Timestamp: 1, Message: "first"},
+			{ID: "2", Timestamp: 2, Message: "second"},
+		},
+	}
+
+	messages, err := a.decodeRecord(gzipJSON(t, sub))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(messages) != 2 || string(messages[0]) != "first" || string(messages[1]) != "second" {
+		t.Fatalf("expected decoded log events, got %v", messages)
+	}
+}
+
+func TestDecodeRecord_CloudWatchLogsControlMessage(t *testing.T) {
+	a := AWSKinesis{Decoder: decoderCloudWatchLogs}
+	sub := cloudWatchLogsSubscriptionRecord{MessageType: "CONTROL_MESSAGE"}
+
+	messages, err := a.decodeRecord(gzipJSON(t, sub))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if messages != nil {
+		t.Fatalf("expected control message to be dropped, got %v", messages)
+	}
+}
+
+func TestDecodeRecord_CloudWatchLogsInvalidGzip(t *testing.T) {
+	a := AWSKinesis{Decoder: decoderCloudWatchLogs}
+
+	if _, err := a.decodeRecord([]byte("not gzip")); err == nil {
+		t.Fatal("expected error decoding non-gzip payload, got nil")
+	}
+}
+
+func TestBackoffCeiling_GrowsUntilCapped(t *testing.T) {
+	if got := backoffCeiling(0); got != backoffBase {
+		t.Fatalf("attempt 0: expected %s, got %s", backoffBase, got)
+	}
+	if got := backoffCeiling(1); got != 2*backoffBase {
+		t.Fatalf("attempt 1: expected %s, got %s", 2*backoffBase, got)
+	}
+	if got := backoffCeiling(100); got != backoffCap {
+		t.Fatalf("attempt 100: expected clamp to %s, got %s", backoffCap, got)
+	}
+}
+
+func TestBackoffCeiling_NeverExceedsCap(t *testing.T) {
+	for attempt := 0; attempt <= 128; attempt++ {
+		if got := backoffCeiling(attempt); got <= 0 || got > backoffCap {
+			t.Fatalf("attempt %d: ceiling %s out of bounds (0, %s]", attempt, got, backoffCap)
+		}
+	}
+}
+
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"throughput exceeded", awserr.New(kinesis.ErrCodeProvisionedThroughputExceededException, "throttled", nil), true},
+		{"limit exceeded", awserr.New(kinesis.ErrCodeLimitExceededException, "limit", nil), true},
+		{"server error", awserr.NewRequestFailure(awserr.New("InternalFailure", "boom", nil), 503, "req-1"), true},
+		{"client error", awserr.NewRequestFailure(awserr.New("ValidationException", "bad input", nil), 400, "req-2"), false},
+		{"not an aws error", errors.New("plain error"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryableError(tt.err); got != tt.want {
+				t.Errorf("isRetryableError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsExpiredIteratorError(t *testing.T) {
+	expired := awserr.New(kinesis.ErrCodeExpiredIteratorException, "expired", nil)
+	if !isExpiredIteratorError(expired) {
+		t.Error("expected ExpiredIteratorException to be reported as expired")
+	}
+
+	other := awserr.New(kinesis.ErrCodeLimitExceededException, "limit", nil)
+	if isExpiredIteratorError(other) {
+		t.Error("expected non-ExpiredIteratorException error to not be reported as expired")
+	}
+
+	if isExpiredIteratorError(errors.New("plain error")) {
+		t.Error("expected non-AWS error to not be reported as expired")
+	}
+}
+
+func TestSleepBackoff_RespectsCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if sleepBackoff(ctx, 0) {
+		t.Error("expected sleepBackoff to report false once ctx is done")
+	}
+}
+
+func TestSleepFor_RespectsCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if sleepFor(ctx, time.Hour) {
+		t.Error("expected sleepFor to return immediately once ctx is done")
+	}
+}
+
+func newAWSKinesis(fake *fakeKinesis, shards []*kinesis.Shard) AWSKinesis {
+	return AWSKinesis{
+		StreamName: "my-stream",
+		service:    fake,
+		shardState: &shardState{shards: shards},
+	}
+}
+
+func TestReshard_ReportsParentClose(t *testing.T) {
+	fake := &fakeKinesis{shards: []*kinesis.Shard{closedShard("shard-0")}}
+	a := newAWSKinesis(fake, []*kinesis.Shard{openShard("shard-0")})
+
+	changes, err := a.Reshard(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(changes) != 1 || changes[0].Type != ShardClosed || changes[0].ShardID != 0 {
+		t.Fatalf("expected a single ShardClosed change for shard 0, got %v", changes)
+	}
+}
+
+func TestReshard_WithholdsChildUntilParentClosedLastCycle(t *testing.T) {
+	fake := &fakeKinesis{shards: []*kinesis.Shard{
+		closedShard("shard-0"),
+		childShard("shard-1", "shard-0"),
+	}}
+	a := newAWSKinesis(fake, []*kinesis.Shard{openShard("shard-0")})
+
+	// First call: parent closes and the child is observed, but the child must
+	// not be added in the same cycle its parent's closure is first reported.
+	changes, err := a.Reshard(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(changes) != 1 || changes[0].Type != ShardClosed {
+		t.Fatalf("expected only the parent close on the first cycle, got %v", changes)
+	}
+
+	// Second call: the parent's closure is now part of local state from a
+	// previous cycle, so the child becomes eligible.
+	changes, err = a.Reshard(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(changes) != 1 || changes[0].Type != ShardAdded || changes[0].ParentShardID != "shard-0" {
+		t.Fatalf("expected the child to be added on the second cycle, got %v", changes)
+	}
+}
+
+func TestReshard_AddsChildOfUntrackedParent(t *testing.T) {
+	// shard-0 aged out of the retention window before this consumer ever saw
+	// it: it has no entry in a.shardState, so its child should not be stuck
+	// waiting on a parent we have no local state for.
+	fake := &fakeKinesis{shards: []*kinesis.Shard{childShard("shard-1", "shard-0")}}
+	a := newAWSKinesis(fake, nil)
+
+	changes, err := a.Reshard(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(changes) != 1 || changes[0].Type != ShardAdded {
+		t.Fatalf("expected the child of an untracked parent to be added immediately, got %v", changes)
+	}
+}
+
+func newEFOAWSKinesis(fake *fakeKinesis, shards []*kinesis.Shard) AWSKinesis {
+	a := newAWSKinesis(fake, shards)
+	a.UseEnhancedFanOut = true
+	a.consumerARN = "arn:aws:kinesis:us-east-1:1234:stream/my-stream/consumer/my-consumer:1"
+	a.subMu = &sync.Mutex{}
+	a.subscriptions = make(map[uint]*efoSubscription)
+	a.closedShards = make(map[uint]bool)
+	return a
+}
+
+func TestRegisterStreamConsumer_NewConsumerReachesActive(t *testing.T) {
+	fake := &fakeKinesis{
+		registerStreamConsumerFn: func(in *kinesis.RegisterStreamConsumerInput) (*kinesis.RegisterStreamConsumerOutput, error) {
+			return &kinesis.RegisterStreamConsumerOutput{
+				Consumer: &kinesis.Consumer{ConsumerARN: aws.String("consumer-arn")},
+			}, nil
+		},
+		describeStreamConsumerFn: func(in *kinesis.DescribeStreamConsumerInput) (*kinesis.DescribeStreamConsumerOutput, error) {
+			return &kinesis.DescribeStreamConsumerOutput{
+				ConsumerDescription: &kinesis.ConsumerDescription{ConsumerStatus: aws.String(kinesis.ConsumerStatusActive)},
+			}, nil
+		},
+	}
+	a := AWSKinesis{service: fake, streamARN: "stream-arn", ConsumerName: "my-consumer"}
+
+	consumerARN, err := a.registerStreamConsumer()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if consumerARN != "consumer-arn" {
+		t.Fatalf("expected consumer-arn, got %s", consumerARN)
+	}
+}
+
+func TestRegisterStreamConsumer_ReusesExistingOnResourceInUse(t *testing.T) {
+	fake := &fakeKinesis{
+		registerStreamConsumerFn: func(in *kinesis.RegisterStreamConsumerInput) (*kinesis.RegisterStreamConsumerOutput, error) {
+			return nil, awserr.New(kinesis.ErrCodeResourceInUseException, "already registered", nil)
+		},
+		describeStreamConsumerFn: func(in *kinesis.DescribeStreamConsumerInput) (*kinesis.DescribeStreamConsumerOutput, error) {
+			return &kinesis.DescribeStreamConsumerOutput{
+				ConsumerDescription: &kinesis.ConsumerDescription{
+					ConsumerARN:    aws.String("existing-consumer-arn"),
+					ConsumerStatus: aws.String(kinesis.ConsumerStatusActive),
+				},
+			}, nil
+		},
+	}
+	a := AWSKinesis{service: fake, streamARN: "stream-arn", ConsumerName: "my-consumer"}
+
+	consumerARN, err := a.registerStreamConsumer()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if consumerARN != "existing-consumer-arn" {
+		t.Fatalf("expected existing-consumer-arn, got %s", consumerARN)
+	}
+}
+
+func TestFetchEnhancedFanOut_DecodesPushedRecords(t *testing.T) {
+	reader := &fakeEventStreamReader{events: make(chan kinesis.SubscribeToShardEventStreamEvent, 1)}
+	reader.events <- &kinesis.SubscribeToShardEvent{
+		Records:                    []*kinesis.Record{{Data: []byte("hello")}},
+		ContinuationSequenceNumber: aws.String("seq-1"),
+	}
+
+	fake := &fakeKinesis{
+		subscribeToShardFn: func(in *kinesis.SubscribeToShardInput) (*kinesis.SubscribeToShardOutput, error) {
+			return &kinesis.SubscribeToShardOutput{EventStream: &kinesis.SubscribeToShardEventStream{Reader: reader}}, nil
+		},
+	}
+	a := newEFOAWSKinesis(fake, []*kinesis.Shard{openShard("shard-0")})
+
+	records, err := a.fetchEnhancedFanOut(context.Background(), 0, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(records.Data) != 1 || string(records.Data[0]) != "hello" {
+		t.Fatalf("expected the pushed record to be decoded, got %v", records.Data)
+	}
+	if records.LastSequence != "seq-1" {
+		t.Fatalf("expected LastSequence to advance to seq-1, got %s", records.LastSequence)
+	}
+}
+
+func TestFetchEnhancedFanOut_ResubscribesOnProactiveRotation(t *testing.T) {
+	firstReader := &fakeEventStreamReader{events: make(chan kinesis.SubscribeToShardEventStreamEvent)}
+	close(firstReader.events) // stream ended with no error: a proactive rotation, not a failure
+
+	secondReader := &fakeEventStreamReader{events: make(chan kinesis.SubscribeToShardEventStreamEvent, 1)}
+	secondReader.events <- &kinesis.SubscribeToShardEvent{Records: []*kinesis.Record{{Data: []byte("after-resub")}}}
+
+	calls := 0
+	fake := &fakeKinesis{
+		subscribeToShardFn: func(in *kinesis.SubscribeToShardInput) (*kinesis.SubscribeToShardOutput, error) {
+			calls++
+			if calls == 1 {
+				return &kinesis.SubscribeToShardOutput{EventStream: &kinesis.SubscribeToShardEventStream{Reader: firstReader}}, nil
+			}
+			return &kinesis.SubscribeToShardOutput{EventStream: &kinesis.SubscribeToShardEventStream{Reader: secondReader}}, nil
+		},
+	}
+	a := newEFOAWSKinesis(fake, []*kinesis.Shard{openShard("shard-0")})
+
+	records, err := a.fetchEnhancedFanOut(context.Background(), 0, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected a fresh subscription after the rotation, got %d SubscribeToShard calls", calls)
+	}
+	if len(records.Data) != 1 || string(records.Data[0]) != "after-resub" {
+		t.Fatalf("expected the record from the new subscription, got %v", records.Data)
+	}
+}
+
+func TestFetchEnhancedFanOut_SurfacesGenuineStreamError(t *testing.T) {
+	streamErr := errors.New("access revoked")
+	reader := &fakeEventStreamReader{events: make(chan kinesis.SubscribeToShardEventStreamEvent), err: streamErr}
+	close(reader.events)
+
+	fake := &fakeKinesis{
+		subscribeToShardFn: func(in *kinesis.SubscribeToShardInput) (*kinesis.SubscribeToShardOutput, error) {
+			return &kinesis.SubscribeToShardOutput{EventStream: &kinesis.SubscribeToShardEventStream{Reader: reader}}, nil
+		},
+	}
+	a := newEFOAWSKinesis(fake, []*kinesis.Shard{openShard("shard-0")})
+
+	if _, err := a.fetchEnhancedFanOut(context.Background(), 0, ""); err != streamErr {
+		t.Fatalf("expected the genuine stream error to surface, got %v", err)
+	}
+}
+
+func TestClose_DeregistersOnlyOnceEveryShardIsClosed(t *testing.T) {
+	deregisterCalls := 0
+	fake := &fakeKinesis{
+		deregisterStreamConsumerFn: func(in *kinesis.DeregisterStreamConsumerInput) (*kinesis.DeregisterStreamConsumerOutput, error) {
+			deregisterCalls++
+			return &kinesis.DeregisterStreamConsumerOutput{}, nil
+		},
+	}
+	a := newEFOAWSKinesis(fake, []*kinesis.Shard{openShard("shard-0"), openShard("shard-1")})
+
+	// Close shard 0 before shard 1 has ever been fetched (so it has no live
+	// subscription): this must not be mistaken for "every shard done".
+	if err := a.Close(0); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if deregisterCalls != 0 {
+		t.Fatalf("expected no deregistration until every shard is closed, got %d calls", deregisterCalls)
+	}
+
+	if err := a.Close(1); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if deregisterCalls != 1 {
+		t.Fatalf("expected exactly one deregistration once every shard is closed, got %d calls", deregisterCalls)
+	}
+}
+
+func TestLoad_RequiresInitialTimestampForATTimestamp(t *testing.T) {
+	data := []byte(`{"streamName":"my-stream","region":"us-east-1","initialPosition":"AT_TIMESTAMP"}`)
+
+	_, err := Load(data)
+	if err == nil || !strings.Contains(err.Error(), "initialTimestamp") {
+		t.Fatalf("expected an initialTimestamp validation error, got %v", err)
+	}
+}
+
+func TestGetShardIterator_DefaultsToTrimHorizon(t *testing.T) {
+	var gotInput *kinesis.GetShardIteratorInput
+	fake := &fakeKinesis{getShardIteratorFn: func(in *kinesis.GetShardIteratorInput) (*kinesis.GetShardIteratorOutput, error) {
+		gotInput = in
+		return &kinesis.GetShardIteratorOutput{ShardIterator: aws.String("iter")}, nil
+	}}
+	a := newAWSKinesis(fake, []*kinesis.Shard{openShard("shard-0")})
+
+	if _, err := a.getShardIterator(context.Background(), 0, ""); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if *gotInput.ShardIteratorType != "TRIM_HORIZON" {
+		t.Fatalf("expected TRIM_HORIZON, got %s", *gotInput.ShardIteratorType)
+	}
+}
+
+func TestGetShardIterator_Latest(t *testing.T) {
+	var gotInput *kinesis.GetShardIteratorInput
+	fake := &fakeKinesis{getShardIteratorFn: func(in *kinesis.GetShardIteratorInput) (*kinesis.GetShardIteratorOutput, error) {
+		gotInput = in
+		return &kinesis.GetShardIteratorOutput{ShardIterator: aws.String("iter")}, nil
+	}}
+	a := newAWSKinesis(fake, []*kinesis.Shard{openShard("shard-0")})
+	a.InitialPosition = "LATEST"
+
+	if _, err := a.getShardIterator(context.Background(), 0, ""); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if *gotInput.ShardIteratorType != "LATEST" {
+		t.Fatalf("expected LATEST, got %s", *gotInput.ShardIteratorType)
+	}
+}
+
+func TestGetShardIterator_AtTimestamp(t *testing.T) {
+	ts := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	var gotInput *kinesis.GetShardIteratorInput
+	fake := &fakeKinesis{getShardIteratorFn: func(in *kinesis.GetShardIteratorInput) (*kinesis.GetShardIteratorOutput, error) {
+		gotInput = in
+		return &kinesis.GetShardIteratorOutput{ShardIterator: aws.String("iter")}, nil
+	}}
+	a := newAWSKinesis(fake, []*kinesis.Shard{openShard("shard-0")})
+	a.InitialPosition = "AT_TIMESTAMP"
+	a.InitialTimestamp = ts
+
+	if _, err := a.getShardIterator(context.Background(), 0, ""); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if *gotInput.ShardIteratorType != "AT_TIMESTAMP" {
+		t.Fatalf("expected AT_TIMESTAMP, got %s", *gotInput.ShardIteratorType)
+	}
+	if gotInput.Timestamp == nil || !gotInput.Timestamp.Equal(ts) {
+		t.Fatalf("expected Timestamp %s, got %v", ts, gotInput.Timestamp)
+	}
+}
+
+func TestGetShardIterator_ResumesAfterSequenceNumberRegardlessOfInitialPosition(t *testing.T) {
+	var gotInput *kinesis.GetShardIteratorInput
+	fake := &fakeKinesis{getShardIteratorFn: func(in *kinesis.GetShardIteratorInput) (*kinesis.GetShardIteratorOutput, error) {
+		gotInput = in
+		return &kinesis.GetShardIteratorOutput{ShardIterator: aws.String("iter")}, nil
+	}}
+	a := newAWSKinesis(fake, []*kinesis.Shard{openShard("shard-0")})
+	a.InitialPosition = "LATEST"
+
+	if _, err := a.getShardIterator(context.Background(), 0, "seq-123"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if *gotInput.ShardIteratorType != "AFTER_SEQUENCE_NUMBER" {
+		t.Fatalf("expected AFTER_SEQUENCE_NUMBER, got %s", *gotInput.ShardIteratorType)
+	}
+	if gotInput.StartingSequenceNumber == nil || *gotInput.StartingSequenceNumber != "seq-123" {
+		t.Fatalf("expected StartingSequenceNumber seq-123, got %v", gotInput.StartingSequenceNumber)
+	}
+}